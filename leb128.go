@@ -2,14 +2,20 @@
 //
 // It deals only with 8 byte primitives; attempting to decode integers larger than that will cause an ErrOverflow.
 //
-// This package operates on a basic io.Reader rather than an io.ByteReader as the standard library does (i.e. the various Varint functions in https://pkg.go.dev/encoding/binary).
+// This package operates on a basic io.Reader rather than an io.ByteReader as the standard library does (i.e. the various Varint functions in https://pkg.go.dev/encoding/binary). The DecodeU32Bytes/DecodeU64Bytes/DecodeS64Bytes functions offer a faster path for input that is already held in a []byte.
+//
+// The package-level Decode* functions are strict: they reject non-minimal encodings. For producers that emit non-minimal padding, use a Decoder with AllowNonMinimal set.
+//
+// The exception to the 8 byte limit are DecodeUBig/DecodeSBig/EncodeUBig/EncodeSBig, which work on *big.Int values wider than 64 bits, bounded by a caller-supplied bit width.
 //
 // See https://en.wikipedia.org/wiki/LEB128 for more details.
 package leb128
 
 import (
+	"bytes"
 	"errors"
 	"io"
+	"math/big"
 )
 
 var (
@@ -17,120 +23,239 @@ var (
 	ErrNonMinimal = errors.New("LEB128 integer encoding was not minimal")
 )
 
-// DecodeU32 converts a uleb128 byte stream to a uint32. Be careful
-// to ensure that your data can fit in 4 bytes.
-func DecodeU32(r io.Reader) (uint32, error) {
+// MaxLen32 is the maximum length of a uleb128/sleb128 encoded 32-bit value,
+// in bytes. Mirrors binary.MaxVarintLen32.
+const MaxLen32 = 5
+
+// MaxLen64 is the maximum length of a uleb128/sleb128 encoded 64-bit value,
+// in bytes. Mirrors binary.MaxVarintLen64.
+const MaxLen64 = 10
+
+// byteReader adapts an io.Reader that doesn't already implement
+// io.ByteReader, so callers that just need a io.ByteReader to range over
+// only pay for a one-byte Read call when the source doesn't already have
+// something cheaper.
+type byteReader struct {
+	r   io.Reader
+	buf [1]byte
+}
+
+func (br *byteReader) ReadByte() (byte, error) {
+	_, err := br.r.Read(br.buf[:])
+	return br.buf[0], err
+}
+
+// asByteReader returns r as an io.ByteReader, using r directly when it
+// already implements ReadByte (e.g. *bytes.Reader, *bytes.Buffer, *bufio.Reader)
+// and falling back to a one-byte-at-a-time adapter otherwise.
+func asByteReader(r io.Reader) io.ByteReader {
+	if br, ok := r.(io.ByteReader); ok {
+		return br
+	}
+	return &byteReader{r: r}
+}
+
+// Decoder holds configuration for tolerant decoding of LEB128 streams
+// produced by non-compliant encoders (older LLVM, hand-written DWARF,
+// certain Wasm toolchains emit non-minimal padding). The zero value is a
+// strict decoder equivalent to the package-level Decode* functions.
+type Decoder struct {
+	// AllowNonMinimal disables the minimality check, accepting LEB128
+	// values padded with extra 0x80 continuation bytes.
+	AllowNonMinimal bool
+
+	// MaxBytes bounds how many bytes a single decode may consume, so that
+	// AllowNonMinimal can't be used to force reading an unbounded amount
+	// of padding. 0 means no explicit limit beyond the 32/64-bit width.
+	MaxBytes int
+}
+
+// U32 converts a uleb128 byte stream to a uint32, honoring d's
+// AllowNonMinimal/MaxBytes settings, and returns the number of bytes
+// consumed from r along with the decoded value. Be careful to ensure that
+// your data can fit in 4 bytes.
+func (d *Decoder) U32(r io.Reader) (uint32, int, error) {
+	br := asByteReader(r)
 	var res uint32 = 0
 	var shift uint = 0
-
-	buf := make([]byte, 1)
+	n := 0
 
 	for {
-		_, err := r.Read(buf)
+		b, err := br.ReadByte()
 		if err == io.EOF {
-			return 0, ErrNonMinimal
+			return 0, n, ErrNonMinimal
 		}
 		if err != nil {
-			return 0, err
+			return 0, n, err
+		}
+		n++
+		if d.MaxBytes > 0 && n > d.MaxBytes {
+			return 0, n, ErrOverflow
 		}
 
-		b := buf[0]
 		res |= uint32(b&0x7F) << shift
 		shift += 7
 
 		if (b & 0x80) == 0 {
 			if shift > 32 && b > 0b1111 {
-				return 0, ErrOverflow
-			} else if shift > 7 && b == 0 {
-				return 0, ErrNonMinimal
+				return 0, n, ErrOverflow
+			} else if !d.AllowNonMinimal && shift > 7 && b == 0 {
+				return 0, n, ErrNonMinimal
 			}
-			return res, nil
+			return res, n, nil
 		} else if shift > 32 {
-			return 0, ErrOverflow
+			return 0, n, ErrOverflow
 		}
 	}
 }
 
-// DecodeU64 converts a uleb128 byte stream to a uint64. Be careful
-// to ensure that your data can fit in 8 bytes.
-func DecodeU64(r io.Reader) (uint64, error) {
+// U64 converts a uleb128 byte stream to a uint64, honoring d's
+// AllowNonMinimal/MaxBytes settings, and returns the number of bytes
+// consumed from r along with the decoded value. Be careful to ensure that
+// your data can fit in 8 bytes.
+func (d *Decoder) U64(r io.Reader) (uint64, int, error) {
+	br := asByteReader(r)
 	var res uint64 = 0
 	var shift uint = 0
-
-	buf := make([]byte, 1)
+	n := 0
 
 	for {
-		_, err := r.Read(buf)
+		b, err := br.ReadByte()
 		if err == io.EOF {
-			return 0, ErrNonMinimal
+			return 0, n, ErrNonMinimal
 		}
 		if err != nil {
-			return 0, err
+			return 0, n, err
+		}
+		n++
+		if d.MaxBytes > 0 && n > d.MaxBytes {
+			return 0, n, ErrOverflow
 		}
 
-		b := buf[0]
 		res |= uint64(b&0x7F) << shift
 		shift += 7
 
 		if (b & 0x80) == 0 {
 			if shift > 64 && b > 1 {
-				return 0, ErrOverflow
-			} else if shift > 7 && b == 0 {
-				return 0, ErrNonMinimal
+				return 0, n, ErrOverflow
+			} else if !d.AllowNonMinimal && shift > 7 && b == 0 {
+				return 0, n, ErrNonMinimal
 			}
-			return res, nil
+			return res, n, nil
 		} else if shift > 64 {
-			return 0, ErrOverflow
+			return 0, n, ErrOverflow
 		}
 	}
 }
 
-// DecodeS64 converts a sleb128 byte stream to a int64. Be careful
-// to ensure that your data can fit in 8 bytes.
-func DecodeS64(r io.Reader) (int64, error) {
+// S64 converts a sleb128 byte stream to an int64, honoring d's
+// AllowNonMinimal/MaxBytes settings, and returns the number of bytes
+// consumed from r along with the decoded value. Be careful to ensure that
+// your data can fit in 8 bytes.
+func (d *Decoder) S64(r io.Reader) (int64, int, error) {
+	br := asByteReader(r)
 	var res int64 = 0
 	var shift uint = 0
 	var prev byte = 0
-
-	buf := make([]byte, 1)
+	n := 0
 
 	for {
-		_, err := r.Read(buf)
+		b, err := br.ReadByte()
 		if err == io.EOF {
-			return 0, ErrNonMinimal
+			return 0, n, ErrNonMinimal
 		}
 		if err != nil {
-			return 0, err
+			return 0, n, err
+		}
+		n++
+		if d.MaxBytes > 0 && n > d.MaxBytes {
+			return 0, n, ErrOverflow
 		}
 
-		b := buf[0]
 		res |= int64(b&0x7F) << shift
 		shift += 7
 
 		if (b & 0x80) == 0 {
 			if shift > 64 && b != 0 && b != 0x7f {
 				// the 10th byte (if present) must contain only the sign-extended sign bit
-				return 0, ErrOverflow
-			} else if shift > 7 &&
+				return 0, n, ErrOverflow
+			} else if !d.AllowNonMinimal && shift > 7 &&
 				((b == 0 && prev&0x40 == 0) || (b == 0x7f && prev&0x40 > 0)) {
 				// overlong if the sign bit of penultimate byte has been extended
-				return 0, ErrNonMinimal
+				return 0, n, ErrNonMinimal
 			} else if shift < 64 && b&0x40 > 0 {
 				// sign extend negative numbers
 				res |= -1 << shift
 			}
-			return res, nil
+			return res, n, nil
 		} else if shift > 64 {
-			return 0, ErrOverflow
+			return 0, n, ErrOverflow
 		}
 		prev = b
 	}
 }
 
+// DecodeU32 converts a uleb128 byte stream to a uint32. Be careful
+// to ensure that your data can fit in 4 bytes.
+func DecodeU32(r io.Reader) (uint32, error) {
+	var d Decoder
+	v, _, err := d.U32(r)
+	return v, err
+}
+
+// DecodeU32Bytes converts a uleb128 byte slice to a uint32, returning the
+// number of bytes consumed. It avoids the per-byte io.Reader overhead of
+// DecodeU32 for input that is already buffered.
+func DecodeU32Bytes(b []byte) (uint32, int, error) {
+	var d Decoder
+	return d.U32(bytes.NewReader(b))
+}
+
+// DecodeU64 converts a uleb128 byte stream to a uint64. Be careful
+// to ensure that your data can fit in 8 bytes.
+func DecodeU64(r io.Reader) (uint64, error) {
+	var d Decoder
+	v, _, err := d.U64(r)
+	return v, err
+}
+
+// DecodeU64Bytes converts a uleb128 byte slice to a uint64, returning the
+// number of bytes consumed. It avoids the per-byte io.Reader overhead of
+// DecodeU64 for input that is already buffered.
+func DecodeU64Bytes(b []byte) (uint64, int, error) {
+	var d Decoder
+	return d.U64(bytes.NewReader(b))
+}
+
+// DecodeS64 converts a sleb128 byte stream to a int64. Be careful
+// to ensure that your data can fit in 8 bytes.
+func DecodeS64(r io.Reader) (int64, error) {
+	var d Decoder
+	v, _, err := d.S64(r)
+	return v, err
+}
+
+// DecodeS64Bytes converts a sleb128 byte slice to an int64, returning the
+// number of bytes consumed. It avoids the per-byte io.Reader overhead of
+// DecodeS64 for input that is already buffered.
+func DecodeS64Bytes(b []byte) (int64, int, error) {
+	var d Decoder
+	return d.S64(bytes.NewReader(b))
+}
+
 // EncodeU32 converts num to a uleb128 encoded array of bytes
 func EncodeU32(num uint32) []byte {
-	buf := make([]byte, 0, 4)
+	return AppendU32(make([]byte, 0, 4), num)
+}
 
+// EncodeU64 converts num to a uleb128 encoded array of bytes
+func EncodeU64(num uint64) []byte {
+	return AppendU64(make([]byte, 0, 8), num)
+}
+
+// AppendU32 appends the uleb128 encoding of num to dst and returns the
+// extended buffer, in the style of encoding/binary.AppendUvarint.
+func AppendU32(dst []byte, num uint32) []byte {
 	done := false
 	for !done {
 		b := byte(num & 0x7F)
@@ -142,16 +267,15 @@ func EncodeU32(num uint32) []byte {
 			b |= 0x80
 		}
 
-		buf = append(buf, b)
+		dst = append(dst, b)
 	}
 
-	return buf
+	return dst
 }
 
-// EncodeU64 converts num to a uleb128 encoded array of bytes
-func EncodeU64(num uint64) []byte {
-	buf := make([]byte, 0, 8)
-
+// AppendU64 appends the uleb128 encoding of num to dst and returns the
+// extended buffer, in the style of encoding/binary.AppendUvarint.
+func AppendU64(dst []byte, num uint64) []byte {
 	done := false
 	for !done {
 		b := byte(num & 0x7F)
@@ -163,16 +287,44 @@ func EncodeU64(num uint64) []byte {
 			b |= 0x80
 		}
 
-		buf = append(buf, b)
+		dst = append(dst, b)
 	}
 
-	return buf
+	return dst
+}
+
+// DecodeZigZag32 converts a zig-zag encoded uleb128 byte stream to an int32.
+// This is the encoding used by encoding/binary.Varint and by Protobuf's
+// sint32 wire type, which is denser than sleb128 for small-magnitude
+// negative numbers. Be careful to ensure that your data can fit in 4 bytes.
+func DecodeZigZag32(r io.Reader) (int32, error) {
+	u, err := DecodeU32(r)
+	if err != nil {
+		return 0, err
+	}
+	return int32(u>>1) ^ -int32(u&1), nil
+}
+
+// DecodeZigZag64 converts a zig-zag encoded uleb128 byte stream to an int64.
+// This is the encoding used by encoding/binary.Varint and by Protobuf's
+// sint64 wire type, which is denser than sleb128 for small-magnitude
+// negative numbers. Be careful to ensure that your data can fit in 8 bytes.
+func DecodeZigZag64(r io.Reader) (int64, error) {
+	u, err := DecodeU64(r)
+	if err != nil {
+		return 0, err
+	}
+	return int64(u>>1) ^ -int64(u&1), nil
 }
 
 // EncodeS64 converts num to a sleb128 encoded array of bytes
 func EncodeS64(num int64) []byte {
-	buf := make([]byte, 0, 8)
+	return AppendS64(make([]byte, 0, 8), num)
+}
 
+// AppendS64 appends the sleb128 encoding of num to dst and returns the
+// extended buffer, in the style of encoding/binary.AppendVarint.
+func AppendS64(dst []byte, num int64) []byte {
 	done := false
 	for !done {
 		//
@@ -193,6 +345,235 @@ func EncodeS64(num int64) []byte {
 			b |= 0x80
 		}
 
+		dst = append(dst, b)
+	}
+
+	return dst
+}
+
+// EncodeZigZag32 converts num to a zig-zag encoded uleb128 array of bytes.
+// This is the encoding used by encoding/binary.PutVarint and by Protobuf's
+// sint32 wire type, which is denser than sleb128 for small-magnitude
+// negative numbers.
+func EncodeZigZag32(num int32) []byte {
+	return EncodeU32(uint32(num<<1) ^ uint32(num>>31))
+}
+
+// EncodeZigZag64 converts num to a zig-zag encoded uleb128 array of bytes.
+// This is the encoding used by encoding/binary.PutVarint and by Protobuf's
+// sint64 wire type, which is denser than sleb128 for small-magnitude
+// negative numbers.
+func EncodeZigZag64(num int64) []byte {
+	return EncodeU64(uint64(num<<1) ^ uint64(num>>63))
+}
+
+// WriteU64 encodes v as uleb128 into a buffer sized by MaxLen64 and writes
+// it to w with a single Write call.
+func WriteU64(w io.Writer, v uint64) (int, error) {
+	var buf [MaxLen64]byte
+	return w.Write(AppendU64(buf[:0], v))
+}
+
+// Writer wraps an io.Writer and encodes integers straight into a small
+// stack-sized buffer before flushing them to the underlying writer, avoiding
+// the per-call allocation of EncodeU32/EncodeU64/EncodeS64.
+type Writer struct {
+	w   io.Writer
+	buf [MaxLen64]byte
+}
+
+// NewWriter creates a Writer that encodes values to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// WriteU32 encodes v as uleb128 and writes it to the underlying writer.
+func (wr *Writer) WriteU32(v uint32) (int, error) {
+	return wr.w.Write(AppendU32(wr.buf[:0], v))
+}
+
+// WriteU64 encodes v as uleb128 and writes it to the underlying writer.
+func (wr *Writer) WriteU64(v uint64) (int, error) {
+	return wr.w.Write(AppendU64(wr.buf[:0], v))
+}
+
+// WriteS64 encodes v as sleb128 and writes it to the underlying writer.
+func (wr *Writer) WriteS64(v int64) (int, error) {
+	return wr.w.Write(AppendS64(wr.buf[:0], v))
+}
+
+// defaultMaxBigBits is the bound applied by DecodeUBig/DecodeSBig when the
+// caller passes maxBits <= 0, so that reading from an untrusted or malicious
+// stream can't grow a big.Int without bound.
+const defaultMaxBigBits = 1024
+
+// DecodeUBig converts a uleb128 byte stream to a *big.Int, for values wider
+// than 64 bits. maxBits caps the width of the decoded value to guard against
+// DoS via unbounded input; passing 0 applies defaultMaxBigBits.
+func DecodeUBig(r io.Reader, maxBits int) (*big.Int, error) {
+	if maxBits <= 0 {
+		maxBits = defaultMaxBigBits
+	}
+
+	br := asByteReader(r)
+	res := new(big.Int)
+	var shift uint
+	maxBytes := maxBigBytes(maxBits)
+
+	for n := 0; ; n++ {
+		if n >= maxBytes {
+			return nil, ErrOverflow
+		}
+
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return nil, ErrNonMinimal
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if overflowsBig(b&0x7F, shift, maxBits) {
+			return nil, ErrOverflow
+		}
+		res.Or(res, new(big.Int).Lsh(big.NewInt(int64(b&0x7F)), shift))
+		shift += 7
+
+		if (b & 0x80) == 0 {
+			if shift > 7 && b == 0 {
+				return nil, ErrNonMinimal
+			}
+			return res, nil
+		}
+	}
+}
+
+// DecodeSBig converts a sleb128 byte stream to a *big.Int, for values wider
+// than 64 bits. maxBits caps the width of the decoded value to guard against
+// DoS via unbounded input; passing 0 applies defaultMaxBigBits.
+func DecodeSBig(r io.Reader, maxBits int) (*big.Int, error) {
+	if maxBits <= 0 {
+		maxBits = defaultMaxBigBits
+	}
+
+	br := asByteReader(r)
+	res := new(big.Int)
+	var shift uint
+	var b, prev byte
+	maxBytes := maxBigBytes(maxBits)
+
+	for n := 0; ; n++ {
+		if n >= maxBytes {
+			return nil, ErrOverflow
+		}
+
+		var err error
+		b, err = br.ReadByte()
+		if err == io.EOF {
+			return nil, ErrNonMinimal
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if overflowsBig(b&0x7F, shift, maxBits) {
+			return nil, ErrOverflow
+		}
+		res.Or(res, new(big.Int).Lsh(big.NewInt(int64(b&0x7F)), shift))
+		shift += 7
+
+		if (b & 0x80) == 0 {
+			if shift > 7 &&
+				((b == 0 && prev&0x40 == 0) || (b == 0x7f && prev&0x40 > 0)) {
+				// overlong if the sign bit of the penultimate byte has been extended
+				return nil, ErrNonMinimal
+			}
+			break
+		}
+		prev = b
+	}
+
+	if b&0x40 != 0 {
+		// sign extend negative numbers
+		res.Sub(res, new(big.Int).Lsh(big.NewInt(1), shift))
+	}
+	return res, nil
+}
+
+// maxBigBytes bounds how many continuation bytes DecodeUBig/DecodeSBig will
+// read for a given maxBits, independent of overflowsBig. Without this, a
+// stream of all-zero-group continuation bytes (0x80 0x80 0x80 ...) never
+// trips the bit-width check and would read forever.
+func maxBigBytes(maxBits int) int {
+	return (maxBits+6)/7 + 1
+}
+
+// overflowsBig reports whether the 7-bit group at the given shift carries
+// any bit beyond maxBits.
+func overflowsBig(group byte, shift uint, maxBits int) bool {
+	if shift >= uint(maxBits) {
+		return group != 0
+	}
+	if remaining := uint(maxBits) - shift; remaining < 7 {
+		mask := byte((1 << remaining) - 1)
+		return group&^mask != 0
+	}
+	return false
+}
+
+// ErrNegative is returned by EncodeUBig when asked to encode a negative
+// value, which has no uleb128 representation.
+var ErrNegative = errors.New("LEB128 uleb128 encoding requires a non-negative value")
+
+// EncodeUBig converts num to a uleb128 encoded array of bytes. num must be
+// non-negative.
+func EncodeUBig(num *big.Int) ([]byte, error) {
+	if num.Sign() < 0 {
+		return nil, ErrNegative
+	}
+
+	n := new(big.Int).Set(num)
+	mask := big.NewInt(0x7F)
+	zero := big.NewInt(0)
+
+	var buf []byte
+	done := false
+	for !done {
+		b := byte(new(big.Int).And(n, mask).Int64())
+
+		n.Rsh(n, 7)
+		if n.Cmp(zero) == 0 {
+			done = true
+		} else {
+			b |= 0x80
+		}
+
+		buf = append(buf, b)
+	}
+
+	return buf, nil
+}
+
+// EncodeSBig converts num to a sleb128 encoded array of bytes.
+func EncodeSBig(num *big.Int) []byte {
+	n := new(big.Int).Set(num)
+	mask := big.NewInt(0x7F)
+	zero := big.NewInt(0)
+	negOne := big.NewInt(-1)
+
+	var buf []byte
+	done := false
+	for !done {
+		b := byte(new(big.Int).And(n, mask).Int64())
+		n.Rsh(n, 7)
+		signBit := b & 0x40
+		if (n.Cmp(zero) == 0 && signBit == 0) ||
+			(n.Cmp(negOne) == 0 && signBit != 0) {
+			done = true
+		} else {
+			b |= 0x80
+		}
+
 		buf = append(buf, b)
 	}
 
@@ -0,0 +1,176 @@
+package leb128
+
+import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestEncodeDecodeUBigRoundTrip(t *testing.T) {
+	vals := []string{
+		"0", "1", "127", "128", "16384",
+		"18446744073709551615",                 // max uint64
+		"18446744073709551616",                 // max uint64 + 1
+		"340282366920938463463374607431768211455", // max uint128
+	}
+	for _, v := range vals {
+		num, ok := new(big.Int).SetString(v, 10)
+		if !ok {
+			t.Fatalf("bad test value %q", v)
+		}
+		enc, err := EncodeUBig(num)
+		if err != nil {
+			t.Fatalf("EncodeUBig(%s): %v", v, err)
+		}
+		got, err := DecodeUBig(bytes.NewReader(enc), 1024)
+		if err != nil {
+			t.Fatalf("DecodeUBig(%s): %v", v, err)
+		}
+		if got.Cmp(num) != 0 {
+			t.Errorf("round-trip %s: got %s", v, got)
+		}
+	}
+}
+
+func TestEncodeDecodeSBigRoundTrip(t *testing.T) {
+	vals := []string{"0", "-1", "63", "64", "-64", "-65", "170141183460469231731687303715884105727", "-170141183460469231731687303715884105728"}
+	for _, v := range vals {
+		num, ok := new(big.Int).SetString(v, 10)
+		if !ok {
+			t.Fatalf("bad test value %q", v)
+		}
+		enc := EncodeSBig(num)
+		got, err := DecodeSBig(bytes.NewReader(enc), 1024)
+		if err != nil {
+			t.Fatalf("DecodeSBig(%s): %v", v, err)
+		}
+		if got.Cmp(num) != 0 {
+			t.Errorf("round-trip %s: got %s", v, got)
+		}
+	}
+}
+
+func TestEncodeUBigNegative(t *testing.T) {
+	_, err := EncodeUBig(big.NewInt(-1))
+	if err != ErrNegative {
+		t.Fatalf("EncodeUBig(-1) = %v, want ErrNegative", err)
+	}
+}
+
+// infiniteContinuationReader emits an endless stream of 0x80 continuation
+// bytes, modeling an attacker-controlled reader that never ends and never
+// sets a bit, to guard against the DecodeUBig/DecodeSBig hang this triggered.
+type infiniteContinuationReader struct{}
+
+func (infiniteContinuationReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0x80
+	}
+	return len(p), nil
+}
+
+func TestDecodeUBigBoundsByteCount(t *testing.T) {
+	_, err := DecodeUBig(infiniteContinuationReader{}, 8)
+	if err != ErrOverflow {
+		t.Fatalf("DecodeUBig on infinite continuation stream = %v, want ErrOverflow", err)
+	}
+}
+
+func TestDecodeSBigBoundsByteCount(t *testing.T) {
+	_, err := DecodeSBig(infiniteContinuationReader{}, 8)
+	if err != ErrOverflow {
+		t.Fatalf("DecodeSBig on infinite continuation stream = %v, want ErrOverflow", err)
+	}
+}
+
+func TestDecoderStrictRejectsNonMinimal(t *testing.T) {
+	// 0x80, 0x00 is a non-minimal encoding of 0.
+	var d Decoder
+	_, _, err := d.U32(bytes.NewReader([]byte{0x80, 0x00}))
+	if err != ErrNonMinimal {
+		t.Fatalf("strict decode of non-minimal value = %v, want ErrNonMinimal", err)
+	}
+}
+
+func TestDecoderAllowNonMinimal(t *testing.T) {
+	d := Decoder{AllowNonMinimal: true}
+	v, n, err := d.U32(bytes.NewReader([]byte{0x80, 0x00}))
+	if err != nil {
+		t.Fatalf("lax decode of non-minimal value: %v", err)
+	}
+	if v != 0 || n != 2 {
+		t.Fatalf("lax decode of non-minimal value = (%d, %d), want (0, 2)", v, n)
+	}
+}
+
+func TestDecoderMaxBytes(t *testing.T) {
+	d := Decoder{AllowNonMinimal: true, MaxBytes: 2}
+	_, _, err := d.U32(infiniteContinuationReader{})
+	if err != ErrOverflow {
+		t.Fatalf("decode exceeding MaxBytes = %v, want ErrOverflow", err)
+	}
+}
+
+func TestDecodeZigZagRoundTrip(t *testing.T) {
+	vals := []int32{0, 1, -1, 2, -2, 1<<30 - 1, -(1 << 30)}
+	for _, v := range vals {
+		got, err := DecodeZigZag32(bytes.NewReader(EncodeZigZag32(v)))
+		if err != nil {
+			t.Fatalf("DecodeZigZag32(%d): %v", v, err)
+		}
+		if got != v {
+			t.Errorf("zig-zag round-trip %d: got %d", v, got)
+		}
+	}
+}
+
+func TestAppendU32MatchesDecodeU32Bytes(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		num := r.Uint32()
+		enc := AppendU32(nil, num)
+		got, n, err := DecodeU32Bytes(enc)
+		if err != nil {
+			t.Fatalf("DecodeU32Bytes(%d): %v", num, err)
+		}
+		if got != num || n != len(enc) {
+			t.Errorf("round-trip %d: got (%d, %d), want (%d, %d)", num, got, n, num, len(enc))
+		}
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if _, err := w.WriteU32(300); err != nil {
+		t.Fatalf("WriteU32: %v", err)
+	}
+	if _, err := w.WriteS64(-300); err != nil {
+		t.Fatalf("WriteS64: %v", err)
+	}
+
+	got32, err := DecodeU32(&buf)
+	if err != nil {
+		t.Fatalf("DecodeU32: %v", err)
+	}
+	if got32 != 300 {
+		t.Errorf("got %d, want 300", got32)
+	}
+
+	got64, err := DecodeS64(&buf)
+	if err != nil {
+		t.Fatalf("DecodeS64: %v", err)
+	}
+	if got64 != -300 {
+		t.Errorf("got %d, want -300", got64)
+	}
+}
+
+func TestDecodeU32TruncatedStream(t *testing.T) {
+	// A continuation byte with nothing following it is not a valid encoding.
+	_, err := DecodeU32(bytes.NewReader([]byte{0x80}))
+	if err == nil {
+		t.Fatalf("DecodeU32 on truncated stream = nil, want an error")
+	}
+}